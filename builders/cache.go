@@ -0,0 +1,193 @@
+package builders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"lambda-builder/ignore"
+)
+
+const cacheBuildArtifactsDir = "build"
+const cacheImageIDFile = "image-id"
+
+// cacheKey returns a content hash of the inputs of a build: the project
+// source tree, the builder image reference, the build environment, and the
+// builder script itself. The builder image is hashed by its reference name
+// only, not its digest, so a different image pushed under the same
+// reference produces the same key.
+//
+// This is computed before executeBuildContainer ever runs, so it can't
+// hash lambda.zip: that file is an *output* the builder container writes
+// into config.WorkingDirectory, and won't exist yet on a fresh checkout.
+func cacheKey(script string, config Config) (string, error) {
+	h := sha256.New()
+	if err := hashSourceTree(h, config.WorkingDirectory); err != nil {
+		return "", fmt.Errorf("error hashing source directory for cache key: %w", err)
+	}
+
+	h.Write([]byte(config.BuilderBuildImage))
+	for _, envPair := range config.BuildEnv {
+		h.Write([]byte(envPair))
+	}
+	h.Write([]byte(script))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashSourceTree feeds every file's relative path and contents under dir
+// into h, in a stable order. lambda.zip is skipped: it's a build output
+// that may or may not already be sitting in dir from a previous run, and
+// its presence shouldn't change the key. Paths matching dir's
+// .lambdaignore/.dockerignore patterns are skipped too, since they never
+// reach the builder container and so can't affect the build output.
+func hashSourceTree(h hash.Hash, dir string) error {
+	patterns, err := ignore.ReadPatterns(dir)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		if info.Name() == "lambda.zip" || ignore.Matches(patterns, relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		io.WriteString(h, relPath)
+
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(h, f)
+		return err
+	})
+}
+
+// cacheDir returns config.CacheDir, falling back to
+// ~/.cache/lambda-builder when it isn't set.
+func cacheDir(config Config) (string, error) {
+	if config.CacheDir != "" {
+		return config.CacheDir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error resolving cache dir: %w", err)
+	}
+
+	return filepath.Join(home, ".cache", "lambda-builder"), nil
+}
+
+// cacheEntryDir returns the cache directory for a given key. It does not
+// create it; populateCacheEntry does that.
+func cacheEntryDir(config Config, key string) (string, error) {
+	dir, err := cacheDir(config)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, key), nil
+}
+
+func cacheEntryExists(entryDir string) bool {
+	info, err := os.Stat(filepath.Join(entryDir, cacheBuildArtifactsDir))
+	return err == nil && info.IsDir()
+}
+
+func readCachedImageID(entryDir string) string {
+	data, err := ioutil.ReadFile(filepath.Join(entryDir, cacheImageIDFile))
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+func writeCachedImageID(entryDir string, imageID string) error {
+	return ioutil.WriteFile(filepath.Join(entryDir, cacheImageIDFile), []byte(imageID), 0644)
+}
+
+// populateCacheEntry copies the extracted build artifacts in buildDir into
+// entryDir so a future build with the same cacheKey can reuse them.
+func populateCacheEntry(entryDir string, buildDir string) error {
+	if err := os.MkdirAll(entryDir, 0755); err != nil {
+		return fmt.Errorf("error creating cache entry dir: %w", err)
+	}
+
+	return copyDir(buildDir, filepath.Join(entryDir, cacheBuildArtifactsDir))
+}
+
+// restoreCacheEntry copies the cached build artifacts for entryDir into
+// destDir, a fresh temporary build dir.
+func restoreCacheEntry(entryDir string, destDir string) error {
+	return copyDir(filepath.Join(entryDir, cacheBuildArtifactsDir), destDir)
+}
+
+func copyDir(src string, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		destPath := filepath.Join(dest, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(destPath, info.Mode())
+		}
+
+		return copyFile(path, destPath, info.Mode())
+	})
+}
+
+func copyFile(src string, dest string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
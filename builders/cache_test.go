@@ -0,0 +1,127 @@
+package builders
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path string, contents string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll(%q) failed: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile(%q) failed: %v", path, err)
+	}
+}
+
+func TestCacheKeyStableAndContentSensitive(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main")
+
+	config := Config{WorkingDirectory: dir, BuilderBuildImage: "builder:latest"}
+
+	key1, err := cacheKey("build.sh", config)
+	if err != nil {
+		t.Fatalf("cacheKey() returned error: %v", err)
+	}
+
+	key2, err := cacheKey("build.sh", config)
+	if err != nil {
+		t.Fatalf("cacheKey() returned error: %v", err)
+	}
+
+	if key1 != key2 {
+		t.Errorf("cacheKey() = %q then %q, want the same key for an unchanged tree", key1, key2)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main // changed")
+
+	key3, err := cacheKey("build.sh", config)
+	if err != nil {
+		t.Fatalf("cacheKey() returned error: %v", err)
+	}
+
+	if key1 == key3 {
+		t.Errorf("cacheKey() unchanged after source file edit, want a different key")
+	}
+}
+
+func TestCacheKeyIgnoresLambdaZipAndIgnoredPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main")
+	writeTestFile(t, filepath.Join(dir, ".lambdaignore"), "node_modules/\n*.log\n")
+
+	config := Config{WorkingDirectory: dir}
+
+	key1, err := cacheKey("build.sh", config)
+	if err != nil {
+		t.Fatalf("cacheKey() returned error: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "lambda.zip"), "not part of the key")
+	writeTestFile(t, filepath.Join(dir, "debug.log"), "not part of the key either")
+	writeTestFile(t, filepath.Join(dir, "node_modules", "pkg", "index.js"), "ignored dependency")
+
+	key2, err := cacheKey("build.sh", config)
+	if err != nil {
+		t.Fatalf("cacheKey() returned error: %v", err)
+	}
+
+	if key1 != key2 {
+		t.Errorf("cacheKey() changed after adding lambda.zip and ignored paths, want it unaffected")
+	}
+}
+
+func TestCacheEntryRoundTrip(t *testing.T) {
+	config := Config{CacheDir: t.TempDir()}
+
+	entryDir, err := cacheEntryDir(config, "abc123")
+	if err != nil {
+		t.Fatalf("cacheEntryDir() returned error: %v", err)
+	}
+
+	if cacheEntryExists(entryDir) {
+		t.Errorf("cacheEntryExists() = true before any entry was populated")
+	}
+
+	buildDir := t.TempDir()
+	writeTestFile(t, filepath.Join(buildDir, "lambda.zip"), "zip contents")
+
+	if err := populateCacheEntry(entryDir, buildDir); err != nil {
+		t.Fatalf("populateCacheEntry() returned error: %v", err)
+	}
+
+	if !cacheEntryExists(entryDir) {
+		t.Errorf("cacheEntryExists() = false after populateCacheEntry")
+	}
+
+	if err := writeCachedImageID(entryDir, "sha256:deadbeef"); err != nil {
+		t.Fatalf("writeCachedImageID() returned error: %v", err)
+	}
+
+	if got := readCachedImageID(entryDir); got != "sha256:deadbeef" {
+		t.Errorf("readCachedImageID() = %q, want %q", got, "sha256:deadbeef")
+	}
+
+	destDir := t.TempDir()
+	if err := restoreCacheEntry(entryDir, destDir); err != nil {
+		t.Fatalf("restoreCacheEntry() returned error: %v", err)
+	}
+
+	restored, err := os.ReadFile(filepath.Join(destDir, "lambda.zip"))
+	if err != nil {
+		t.Fatalf("reading restored lambda.zip failed: %v", err)
+	}
+	if string(restored) != "zip contents" {
+		t.Errorf("restored lambda.zip = %q, want %q", restored, "zip contents")
+	}
+}
+
+func TestReadCachedImageIDMissing(t *testing.T) {
+	if got := readCachedImageID(t.TempDir()); got != "" {
+		t.Errorf("readCachedImageID() on an empty entry dir = %q, want empty string", got)
+	}
+}
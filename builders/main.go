@@ -5,14 +5,17 @@ import (
 	"context"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
-	"lambda-builder/io"
+	"lambda-builder/ignore"
+	lbio "lambda-builder/io"
+	"lambda-builder/progress"
 
-	execute "github.com/alexellis/go-execute/pkg/v1"
 	extract "github.com/codeclysm/extract/v3"
 	"gopkg.in/yaml.v2"
 )
@@ -31,6 +34,7 @@ type Config struct {
 	Builder           string
 	BuilderBuildImage string
 	BuilderRunImage   string
+	CacheDir          string
 	GenerateRunImage  bool
 	Handler           string
 	HandlerMap        map[string]string
@@ -38,19 +42,39 @@ type Config struct {
 	ImageEnv          []string
 	ImageLabels       []string
 	ImageTag          string
+	ImageTags         []string
+	NoCache           bool
 	Port              int
+	ProgressWriter    io.Writer
 	RunQuiet          bool
+	Runtime           string
+	Source            string
 	WorkingDirectory  string
 	WriteProcfile     bool
 }
 
 func (c Config) GetImageTag() string {
+	return c.GetImageTags()[0]
+}
+
+// GetImageTags returns every tag the built image should be tagged with.
+// ImageTags is the preferred field; ImageTag is kept for backwards
+// compatibility and, if set, is appended to the list. If neither is set, a
+// single default tag is derived from the working directory name.
+func (c Config) GetImageTags() []string {
+	var tags []string
+	tags = append(tags, c.ImageTags...)
+
 	if c.ImageTag != "" {
-		return c.ImageTag
+		tags = append(tags, c.ImageTag)
+	}
+
+	if len(tags) == 0 {
+		appName := strings.ToLower(filepath.Base(c.WorkingDirectory))
+		tags = append(tags, fmt.Sprintf("lambda-builder/%s:latest", appName))
 	}
 
-	appName := filepath.Base(c.WorkingDirectory)
-	return fmt.Sprintf("lambda-builder/%s:latest", appName)
+	return tags
 }
 
 type LambdaYML struct {
@@ -60,10 +84,31 @@ type LambdaYML struct {
 }
 
 func executeBuilder(script string, config Config) error {
-	if err := executeBuildContainer(script, config); err != nil {
+	sourceDir, cleanupSource, err := resolveSource(config)
+	defer cleanupSource()
+	if err != nil {
 		return err
 	}
 
+	if config.Source != "" {
+		fmt.Printf("-----> Resolving source: %s\n", config.Source)
+		config.WorkingDirectory = sourceDir
+	}
+
+	var cacheEntry string
+	if !config.NoCache {
+		key, err := cacheKey(script, config)
+		if err != nil {
+			return err
+		}
+
+		cacheEntry, err = cacheEntryDir(config, key)
+		if err != nil {
+			return err
+		}
+	}
+	cacheHit := cacheEntry != "" && cacheEntryExists(cacheEntry)
+
 	taskHostBuildDir, err := os.MkdirTemp("", "lambda-builder")
 	if err != nil {
 		return fmt.Errorf("error creating build dir: %w", err)
@@ -73,20 +118,43 @@ func executeBuilder(script string, config Config) error {
 		os.RemoveAll(taskHostBuildDir)
 	}()
 
-	fmt.Printf("-----> Extracting lambda.zip into build context dir\n")
-	zipPath := filepath.Join(config.WorkingDirectory, "lambda.zip")
-	data, _ := ioutil.ReadFile(zipPath)
-	buffer := bytes.NewBuffer(data)
-	if err := extract.Zip(context.Background(), buffer, taskHostBuildDir, nil); err != nil {
-		return fmt.Errorf("error extracting lambda.zip into build context dir: %w", err)
+	if cacheHit {
+		fmt.Printf("-----> Using cached build artifacts\n")
+		if err := restoreCacheEntry(cacheEntry, taskHostBuildDir); err != nil {
+			return fmt.Errorf("error restoring cached build artifacts: %w", err)
+		}
+	} else {
+		if err := executeBuildContainer(script, config); err != nil {
+			return err
+		}
+
+		fmt.Printf("-----> Extracting lambda.zip into build context dir\n")
+		reportProgress(config, "extract zip")
+		zipPath := filepath.Join(config.WorkingDirectory, "lambda.zip")
+		data, _ := ioutil.ReadFile(zipPath)
+		buffer := bytes.NewBuffer(data)
+		if err := extract.Zip(context.Background(), buffer, taskHostBuildDir, nil); err != nil {
+			return fmt.Errorf("error extracting lambda.zip into build context dir: %w", err)
+		}
+
+		if err := pruneIgnoredFiles(taskHostBuildDir, config); err != nil {
+			return err
+		}
+
+		if cacheEntry != "" {
+			if err := populateCacheEntry(cacheEntry, taskHostBuildDir); err != nil {
+				return fmt.Errorf("error populating build cache: %w", err)
+			}
+		}
 	}
 
 	handler := getFunctionHandler(taskHostBuildDir, config)
-	if config.WriteProcfile && !io.FileExistsInDirectory(taskHostBuildDir, "Procfile") {
+	if config.WriteProcfile && !lbio.FileExistsInDirectory(taskHostBuildDir, "Procfile") {
 		if handler == "" {
 			fmt.Printf(" !     Unable to detect handler in build directory\n")
 		} else {
 			fmt.Printf("=====> Writing Procfile from handler: %s\n", handler)
+			reportProgress(config, "write Procfile")
 
 			fmt.Printf("       Writing to working directory\n")
 			if err := writeProcfile(handler, config.WorkingDirectory); err != nil {
@@ -102,6 +170,18 @@ func executeBuilder(script string, config Config) error {
 
 	if config.GenerateRunImage {
 		fmt.Printf("=====> Building image\n")
+
+		if cacheHit {
+			if imageID := readCachedImageID(cacheEntry); imageID != "" {
+				fmt.Printf("       Re-tagging cached image %s as %s\n", imageID, strings.Join(config.GetImageTags(), ", "))
+				runtime, err := GetContainerRuntime(config)
+				if err != nil {
+					return err
+				}
+				return runtime.Tag(imageID, config.GetImageTags())
+			}
+		}
+
 		fmt.Printf("       Generating temporary Dockerfile\n")
 
 		dockerfilePath, err := ioutil.TempFile("", "lambda-builder")
@@ -117,45 +197,93 @@ func executeBuilder(script string, config Config) error {
 			return err
 		}
 
-		fmt.Printf("       Executing build of %s\n", config.GetImageTag())
+		fmt.Printf("       Executing build of %s\n", strings.Join(config.GetImageTags(), ", "))
 		if err := buildDockerImage(taskHostBuildDir, config, dockerfilePath); err != nil {
 			return err
 		}
+
+		if cacheEntry != "" {
+			if err := cacheBuiltImageID(cacheEntry, config); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// cacheBuiltImageID records the ID of the just-built image against
+// cacheEntry, so a future build with the same cache key can re-tag it
+// instead of rebuilding.
+func cacheBuiltImageID(cacheEntry string, config Config) error {
+	runtime, err := GetContainerRuntime(config)
+	if err != nil {
+		return err
+	}
+
+	id, err := runtime.ImageID(config.GetImageTag())
+	if err != nil {
+		return fmt.Errorf("error looking up built image ID: %w", err)
+	}
+
+	if id == "" {
+		return nil
+	}
+
+	if err := writeCachedImageID(cacheEntry, id); err != nil {
+		return fmt.Errorf("error writing cached image ID: %w", err)
 	}
 
 	return nil
 }
 
 func executeBuildContainer(script string, config Config) error {
-	args := []string{
-		"container",
-		"run",
-		"--rm",
-		"--env", "LAMBDA_BUILD_ZIP=1",
-		"--label", "com.dokku.lambda-builder/executor=true",
-		"--name", fmt.Sprintf("lambda-builder-executor-%s", config.Identifier),
-		"--volume", fmt.Sprintf("%s:/tmp/task", config.WorkingDirectory),
+	reportProgress(config, "run builder container")
+
+	runtime, err := GetContainerRuntime(config)
+	if err != nil {
+		return err
 	}
 
-	for _, envPair := range config.BuildEnv {
-		args = append(args, "--env", envPair)
+	spec := RunSpec{
+		BuilderImage:     config.BuilderBuildImage,
+		Command:          []string{"/bin/bash", "-c", script},
+		Env:              append([]string{"LAMBDA_BUILD_ZIP=1"}, config.BuildEnv...),
+		Labels:           []string{"com.dokku.lambda-builder/executor=true"},
+		Name:             fmt.Sprintf("lambda-builder-executor-%s", config.Identifier),
+		ProgressWriter:   config.ProgressWriter,
+		Quiet:            config.RunQuiet,
+		Volumes:          []string{fmt.Sprintf("%s:/tmp/task", config.WorkingDirectory)},
+		WorkingDirectory: config.WorkingDirectory,
 	}
-	args = append(args, config.BuilderBuildImage, "/bin/bash", "-c", script)
 
-	cmd := execute.ExecTask{
-		Args:        args,
-		Command:     "docker",
-		Cwd:         config.WorkingDirectory,
-		StreamStdio: !config.RunQuiet,
+	return runtime.Run(spec)
+}
+
+// reportProgress emits a structured progress.JSONMessage for status to
+// config.ProgressWriter, if one is configured. It's a no-op otherwise, so
+// call sites don't need to guard every call.
+func reportProgress(config Config, status string) {
+	if config.ProgressWriter == nil {
+		return
 	}
 
-	res, err := cmd.Execute()
+	progress.Emit(config.ProgressWriter, progress.JSONMessage{Status: status})
+}
+
+func pruneIgnoredFiles(buildDir string, config Config) error {
+	patterns, err := ignore.ReadPatterns(config.WorkingDirectory)
 	if err != nil {
-		return fmt.Errorf("error executing builder: %w", err)
+		return fmt.Errorf("error reading ignore patterns: %w", err)
+	}
+
+	if len(patterns) == 0 {
+		return nil
 	}
 
-	if res.ExitCode != 0 {
-		return fmt.Errorf("error executing builder, exit code %d", res.ExitCode)
+	fmt.Printf("-----> Pruning ignored files from build context dir\n")
+	if err := ignore.Prune(buildDir, patterns); err != nil {
+		return fmt.Errorf("error pruning ignored files from build context dir: %w", err)
 	}
 
 	return nil
@@ -195,42 +323,34 @@ COPY . /var/task
 }
 
 func buildDockerImage(directory string, config Config, dockerfilePath *os.File) error {
-	args := []string{
-		"image",
-		"build",
-		"--file", dockerfilePath.Name(),
-		"--progress", "plain",
-		"--tag", config.GetImageTag(),
-	}
-
-	for _, label := range config.ImageLabels {
-		args = append(args, "--label", label)
-	}
-
-	args = append(args, directory)
-
-	cmd := execute.ExecTask{
-		Args:        args,
-		Command:     "docker",
-		Cwd:         config.WorkingDirectory,
-		StreamStdio: !config.RunQuiet,
+	tags := config.GetImageTags()
+	for _, tag := range tags {
+		if err := validateImageTag(tag); err != nil {
+			return fmt.Errorf("error validating image tag: %w", err)
+		}
 	}
 
-	res, err := cmd.Execute()
+	runtime, err := GetContainerRuntime(config)
 	if err != nil {
-		return fmt.Errorf("error building image: %w", err)
+		return err
 	}
 
-	if res.ExitCode != 0 {
-		return fmt.Errorf("error building image, exit code %d", res.ExitCode)
+	spec := BuildSpec{
+		ContextDir:       directory,
+		DockerfilePath:   dockerfilePath.Name(),
+		Labels:           config.ImageLabels,
+		ProgressWriter:   config.ProgressWriter,
+		Quiet:            config.RunQuiet,
+		Tags:             tags,
+		WorkingDirectory: config.WorkingDirectory,
 	}
 
-	return nil
+	return runtime.Build(spec)
 }
 
 func ParseLambdaYML(config Config) (LambdaYML, error) {
 	var lambdaYML LambdaYML
-	if !io.FileExistsInDirectory(config.WorkingDirectory, "lambda.yml") {
+	if !lbio.FileExistsInDirectory(config.WorkingDirectory, "lambda.yml") {
 		return lambdaYML, nil
 	}
 
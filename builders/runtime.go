@@ -0,0 +1,367 @@
+package builders
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	execute "github.com/alexellis/go-execute/pkg/v1"
+
+	"lambda-builder/progress"
+)
+
+// RunSpec describes a single "run a builder image" invocation, independent
+// of which container runtime ends up executing it.
+type RunSpec struct {
+	Args             []string
+	BuilderImage     string
+	Command          []string
+	Env              []string
+	Labels           []string
+	Name             string
+	ProgressWriter   io.Writer
+	Quiet            bool
+	Volumes          []string
+	WorkingDirectory string
+}
+
+// BuildSpec describes a single "build an image from a Dockerfile" invocation,
+// independent of which container runtime ends up executing it.
+type BuildSpec struct {
+	ContextDir       string
+	DockerfilePath   string
+	Labels           []string
+	ProgressWriter   io.Writer
+	Quiet            bool
+	Tags             []string
+	WorkingDirectory string
+}
+
+// ContainerRuntime knows how to translate a RunSpec/BuildSpec into its own
+// native CLI invocation.
+type ContainerRuntime interface {
+	Name() string
+	Run(spec RunSpec) error
+	Build(spec BuildSpec) error
+	Tag(imageID string, tags []string) error
+	ImageID(tag string) (string, error)
+}
+
+const (
+	RuntimeDocker  = "docker"
+	RuntimePodman  = "podman"
+	RuntimeBuildah = "buildah"
+	RuntimeNerdctl = "nerdctl"
+)
+
+// GetContainerRuntime selects a ContainerRuntime based on, in order of
+// precedence, config.Runtime, the LAMBDA_BUILDER_RUNTIME environment
+// variable, and finally the docker default.
+func GetContainerRuntime(config Config) (ContainerRuntime, error) {
+	name := config.Runtime
+	if name == "" {
+		name = os.Getenv("LAMBDA_BUILDER_RUNTIME")
+	}
+	if name == "" {
+		name = RuntimeDocker
+	}
+
+	switch name {
+	case RuntimeDocker:
+		return dockerRuntime{}, nil
+	case RuntimePodman:
+		return podmanRuntime{}, nil
+	case RuntimeBuildah:
+		return buildahRuntime{}, nil
+	case RuntimeNerdctl:
+		return nerdctlRuntime{}, nil
+	default:
+		return nil, fmt.Errorf("unknown container runtime: %s", name)
+	}
+}
+
+// imageID looks up the ID of the image referenced by tag, for a runtime
+// whose CLI supports the docker-compatible `images -q <tag>` form.
+func imageID(command string, tag string) (string, error) {
+	cmd := exec.Command(command, "images", "-q", tag)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error looking up image ID for %s: %w", tag, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// tagImage applies every tag in tags to imageID, one `<command> tag`
+// invocation per tag, mirroring how docker/podman/buildah/nerdctl all only
+// accept a single target per invocation.
+func tagImage(command string, imageID string, tags []string) error {
+	for _, tag := range tags {
+		args := []string{"tag", imageID, tag}
+		if err := runCommandTask(command, args, "", false, fmt.Sprintf("tagging image as %s", tag)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runCommandTask(command string, args []string, workingDirectory string, quiet bool, errContext string) error {
+	cmd := execute.ExecTask{
+		Args:        args,
+		Command:     command,
+		Cwd:         workingDirectory,
+		StreamStdio: !quiet,
+	}
+
+	res, err := cmd.Execute()
+	if err != nil {
+		return fmt.Errorf("error %s: %w", errContext, err)
+	}
+
+	if res.ExitCode != 0 {
+		return fmt.Errorf("error %s, exit code %d", errContext, res.ExitCode)
+	}
+
+	return nil
+}
+
+// dockerRuntime shells out to the docker CLI. This is the historical,
+// default behavior of lambda-builder.
+type dockerRuntime struct{}
+
+func (dockerRuntime) Name() string { return RuntimeDocker }
+
+func (dockerRuntime) Run(spec RunSpec) error {
+	args := []string{"container", "run", "--rm"}
+	args = append(args, runArgs(spec)...)
+	return runCommandTask("docker", args, spec.WorkingDirectory, spec.Quiet, "executing builder")
+}
+
+func (dockerRuntime) Build(spec BuildSpec) error {
+	args := []string{"image", "build", "--progress", "plain"}
+	args = append(args, buildArgs(spec)...)
+
+	if spec.ProgressWriter != nil {
+		return runBuildWithProgress("docker", args, spec)
+	}
+
+	return runCommandTask("docker", args, spec.WorkingDirectory, spec.Quiet, "building image")
+}
+
+func (dockerRuntime) Tag(imageID string, tags []string) error {
+	return tagImage("docker", imageID, tags)
+}
+
+func (dockerRuntime) ImageID(tag string) (string, error) {
+	return imageID("docker", tag)
+}
+
+// podmanRuntime shells out to the podman CLI. podman is largely
+// docker-CLI-compatible, but has no need for --progress plain and doesn't
+// require a daemon, which makes it a good fit for rootless CI environments.
+type podmanRuntime struct{}
+
+func (podmanRuntime) Name() string { return RuntimePodman }
+
+func (podmanRuntime) Run(spec RunSpec) error {
+	args := []string{"container", "run", "--rm"}
+	args = append(args, runArgs(spec)...)
+	return runCommandTask("podman", args, spec.WorkingDirectory, spec.Quiet, "executing builder")
+}
+
+func (podmanRuntime) Build(spec BuildSpec) error {
+	args := []string{"image", "build"}
+	args = append(args, buildArgs(spec)...)
+
+	if spec.ProgressWriter != nil {
+		return runBuildWithProgress("podman", args, spec)
+	}
+
+	return runCommandTask("podman", args, spec.WorkingDirectory, spec.Quiet, "building image")
+}
+
+func (podmanRuntime) Tag(imageID string, tags []string) error {
+	return tagImage("podman", imageID, tags)
+}
+
+func (podmanRuntime) ImageID(tag string) (string, error) {
+	return imageID("podman", tag)
+}
+
+// buildahRuntime shells out to the buildah CLI, which splits "run a
+// container" and "build an image" into distinct tools (buildah doesn't run
+// containers the way docker/podman/nerdctl do, so Run is unsupported).
+type buildahRuntime struct{}
+
+func (buildahRuntime) Name() string { return RuntimeBuildah }
+
+func (buildahRuntime) Run(spec RunSpec) error {
+	return fmt.Errorf("buildah does not support running builder containers, use docker, podman, or nerdctl")
+}
+
+func (buildahRuntime) Build(spec BuildSpec) error {
+	args := []string{"bud"}
+	args = append(args, buildArgs(spec)...)
+
+	if spec.ProgressWriter != nil {
+		return runBuildWithProgress("buildah", args, spec)
+	}
+
+	return runCommandTask("buildah", args, spec.WorkingDirectory, spec.Quiet, "building image")
+}
+
+func (buildahRuntime) Tag(imageID string, tags []string) error {
+	return tagImage("buildah", imageID, tags)
+}
+
+func (buildahRuntime) ImageID(tag string) (string, error) {
+	return imageID("buildah", tag)
+}
+
+// nerdctlRuntime shells out to the nerdctl CLI, which is docker-CLI-compatible
+// and uses BuildKit for builds natively.
+type nerdctlRuntime struct{}
+
+func (nerdctlRuntime) Name() string { return RuntimeNerdctl }
+
+func (nerdctlRuntime) Run(spec RunSpec) error {
+	args := []string{"container", "run", "--rm"}
+	args = append(args, runArgs(spec)...)
+	return runCommandTask("nerdctl", args, spec.WorkingDirectory, spec.Quiet, "executing builder")
+}
+
+func (nerdctlRuntime) Build(spec BuildSpec) error {
+	args := []string{"image", "build"}
+	args = append(args, buildArgs(spec)...)
+
+	if spec.ProgressWriter != nil {
+		return runBuildWithProgress("nerdctl", args, spec)
+	}
+
+	return runCommandTask("nerdctl", args, spec.WorkingDirectory, spec.Quiet, "building image")
+}
+
+func (nerdctlRuntime) Tag(imageID string, tags []string) error {
+	return tagImage("nerdctl", imageID, tags)
+}
+
+func (nerdctlRuntime) ImageID(tag string) (string, error) {
+	return imageID("nerdctl", tag)
+}
+
+func runArgs(spec RunSpec) []string {
+	var args []string
+
+	for _, envPair := range spec.Env {
+		args = append(args, "--env", envPair)
+	}
+	for _, label := range spec.Labels {
+		args = append(args, "--label", label)
+	}
+	for _, volume := range spec.Volumes {
+		args = append(args, "--volume", volume)
+	}
+	if spec.Name != "" {
+		args = append(args, "--name", spec.Name)
+	}
+
+	args = append(args, spec.BuilderImage)
+	args = append(args, spec.Command...)
+
+	return args
+}
+
+var (
+	buildStepPattern  = regexp.MustCompile(`^Step (\d+)/(\d+)`)
+	builtImagePattern = regexp.MustCompile(`^Successfully built ([0-9a-f]+)`)
+)
+
+// runBuildWithProgress runs an image build while parsing its combined
+// stdout/stderr line by line, emitting a progress.JSONMessage for each line
+// to spec.ProgressWriter in addition to the usual human-readable output.
+func runBuildWithProgress(command string, args []string, spec BuildSpec) error {
+	cmd := exec.Command(command, args...)
+	cmd.Dir = spec.WorkingDirectory
+
+	pipeReader, pipeWriter := io.Pipe()
+	cmd.Stdout = pipeWriter
+	cmd.Stderr = pipeWriter
+
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Run()
+		pipeWriter.Close()
+		done <- err
+	}()
+
+	scanner := bufio.NewScanner(pipeReader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !spec.Quiet {
+			fmt.Println(line)
+		}
+		emitBuildProgressLine(spec.ProgressWriter, line)
+	}
+	scanErr := scanner.Err()
+
+	if err := <-done; err != nil {
+		return fmt.Errorf("error building image: %w", err)
+	}
+
+	if scanErr != nil {
+		return fmt.Errorf("error reading build output: %w", scanErr)
+	}
+
+	return nil
+}
+
+// emitBuildProgressLine emits line as a raw progress.JSONMessage stream
+// event, upgrading it to a structured step/image-ID event when it matches
+// docker's plain-progress output. podman/buildah/nerdctl builds still get
+// every line streamed as a `stream` event; their step output isn't parsed
+// into progressDetail/aux yet.
+func emitBuildProgressLine(w io.Writer, line string) {
+	msg := progress.JSONMessage{Stream: line + "\n"}
+
+	switch {
+	case buildStepPattern.MatchString(line):
+		match := buildStepPattern.FindStringSubmatch(line)
+		current, _ := strconv.ParseInt(match[1], 10, 64)
+		total, _ := strconv.ParseInt(match[2], 10, 64)
+		msg = progress.JSONMessage{
+			Status:         line,
+			ProgressDetail: &progress.ProgressDetail{Current: current, Total: total},
+		}
+	case builtImagePattern.MatchString(line):
+		match := builtImagePattern.FindStringSubmatch(line)
+		msg = progress.JSONMessage{
+			Status: line,
+			Aux:    &progress.Aux{ID: match[1]},
+		}
+	}
+
+	progress.Emit(w, msg)
+}
+
+func buildArgs(spec BuildSpec) []string {
+	args := []string{"--file", spec.DockerfilePath}
+
+	for _, tag := range spec.Tags {
+		args = append(args, "--tag", tag)
+	}
+	for _, label := range spec.Labels {
+		args = append(args, "--label", label)
+	}
+
+	args = append(args, spec.ContextDir)
+
+	return args
+}
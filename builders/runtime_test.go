@@ -0,0 +1,132 @@
+package builders
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec RunSpec
+		want []string
+	}{
+		{
+			name: "minimal",
+			spec: RunSpec{BuilderImage: "myfn:latest"},
+			want: []string{"myfn:latest"},
+		},
+		{
+			name: "env, labels, volumes, name, command",
+			spec: RunSpec{
+				BuilderImage: "myfn:latest",
+				Command:      []string{"build.sh"},
+				Env:          []string{"FOO=bar"},
+				Labels:       []string{"org=example"},
+				Name:         "myfn-build",
+				Volumes:      []string{"/src:/var/task"},
+			},
+			want: []string{
+				"--env", "FOO=bar",
+				"--label", "org=example",
+				"--volume", "/src:/var/task",
+				"--name", "myfn-build",
+				"myfn:latest",
+				"build.sh",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := runArgs(tt.spec)
+		if !stringSlicesEqual(got, tt.want) {
+			t.Errorf("%s: runArgs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec BuildSpec
+		want []string
+	}{
+		{
+			name: "minimal",
+			spec: BuildSpec{DockerfilePath: "Dockerfile", ContextDir: "."},
+			want: []string{"--file", "Dockerfile", "."},
+		},
+		{
+			name: "tags and labels",
+			spec: BuildSpec{
+				DockerfilePath: "Dockerfile",
+				ContextDir:     ".",
+				Tags:           []string{"myfn:latest", "myfn:v1"},
+				Labels:         []string{"org=example"},
+			},
+			want: []string{
+				"--file", "Dockerfile",
+				"--tag", "myfn:latest",
+				"--tag", "myfn:v1",
+				"--label", "org=example",
+				".",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := buildArgs(tt.spec)
+		if !stringSlicesEqual(got, tt.want) {
+			t.Errorf("%s: buildArgs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEmitBuildProgressLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{
+			name: "plain stream line",
+			line: "some ordinary build output",
+			want: []string{`"stream":"some ordinary build output\n"`},
+		},
+		{
+			name: "step line",
+			line: "Step 3/10 : RUN go build",
+			want: []string{`"status":"Step 3/10 : RUN go build"`, `"current":3`, `"total":10`},
+		},
+		{
+			name: "successfully built line",
+			line: "Successfully built a1b2c3d4e5f6",
+			want: []string{`"status":"Successfully built a1b2c3d4e5f6"`, `"ID":"a1b2c3d4e5f6"`},
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		emitBuildProgressLine(&buf, tt.line)
+
+		got := buf.String()
+		for _, substr := range tt.want {
+			if !strings.Contains(got, substr) {
+				t.Errorf("%s: emitBuildProgressLine(%q) = %q, want it to contain %q", tt.name, tt.line, got, substr)
+			}
+		}
+	}
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
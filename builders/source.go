@@ -0,0 +1,117 @@
+package builders
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	extract "github.com/codeclysm/extract/v3"
+)
+
+// resolveSource turns config.Source, if set, into a local directory that
+// can be used as the effective working directory for the rest of the build
+// pipeline, matching the URL forms `docker build` accepts: a git remote
+// (optionally followed by `#ref:subdir`), or an http(s) URL pointing at a
+// .tar.gz/.zip archive. If config.Source is empty, config.WorkingDirectory
+// is returned unchanged.
+//
+// The returned cleanup func removes any temporary directory created to
+// hold the resolved source and must always be called, even on error.
+func resolveSource(config Config) (string, func() error, error) {
+	noopCleanup := func() error { return nil }
+
+	if config.Source == "" {
+		return config.WorkingDirectory, noopCleanup, nil
+	}
+
+	url, ref, subdir := splitSourceFragment(config.Source)
+
+	sourceDir, err := os.MkdirTemp("", "lambda-builder-source")
+	if err != nil {
+		return "", noopCleanup, fmt.Errorf("error creating source dir: %w", err)
+	}
+	cleanup := func() error { return os.RemoveAll(sourceDir) }
+
+	if isGitSource(url) {
+		if err := cloneGitSource(url, ref, sourceDir); err != nil {
+			return "", cleanup, err
+		}
+	} else {
+		if err := downloadArchiveSource(url, sourceDir); err != nil {
+			return "", cleanup, err
+		}
+	}
+
+	resolvedDir := sourceDir
+	if subdir != "" {
+		resolvedDir = filepath.Join(sourceDir, subdir)
+	}
+
+	return resolvedDir, cleanup, nil
+}
+
+// splitSourceFragment splits a `url#ref:subdir` source reference into its
+// URL, ref, and subdir components. ref and/or subdir may be empty.
+func splitSourceFragment(source string) (url string, ref string, subdir string) {
+	url = source
+
+	idx := strings.Index(source, "#")
+	if idx == -1 {
+		return url, "", ""
+	}
+
+	url = source[:idx]
+	fragment := source[idx+1:]
+
+	if i := strings.Index(fragment, ":"); i != -1 {
+		return url, fragment[:i], fragment[i+1:]
+	}
+
+	return url, fragment, ""
+}
+
+func isGitSource(url string) bool {
+	return strings.HasPrefix(url, "git://") ||
+		strings.HasPrefix(url, "git@") ||
+		strings.HasSuffix(url, ".git")
+}
+
+func cloneGitSource(url string, ref string, dest string) error {
+	cmd := exec.Command("git", "clone", url, dest)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error cloning %s: %w: %s", url, err, out)
+	}
+
+	if ref == "" {
+		return nil
+	}
+
+	cmd = exec.Command("git", "-C", dest, "checkout", ref)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error checking out %s in %s: %w: %s", ref, url, err, out)
+	}
+
+	return nil
+}
+
+func downloadArchiveSource(url string, dest string) error {
+	res, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("error downloading %s: unexpected status %s", url, res.Status)
+	}
+
+	if err := extract.Archive(context.Background(), res.Body, dest, nil); err != nil {
+		return fmt.Errorf("error extracting %s: %w", url, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package builders
+
+import "testing"
+
+func TestSplitSourceFragment(t *testing.T) {
+	tests := []struct {
+		source     string
+		wantURL    string
+		wantRef    string
+		wantSubdir string
+	}{
+		{"https://example.com/app.tar.gz", "https://example.com/app.tar.gz", "", ""},
+		{"git://github.com/org/repo.git", "git://github.com/org/repo.git", "", ""},
+		{"https://github.com/org/repo.git#main", "https://github.com/org/repo.git", "main", ""},
+		{"git@github.com:org/repo.git#v1.2.3:functions/api", "git@github.com:org/repo.git", "v1.2.3", "functions/api"},
+	}
+
+	for _, tt := range tests {
+		url, ref, subdir := splitSourceFragment(tt.source)
+		if url != tt.wantURL || ref != tt.wantRef || subdir != tt.wantSubdir {
+			t.Errorf("splitSourceFragment(%q) = (%q, %q, %q), want (%q, %q, %q)",
+				tt.source, url, ref, subdir, tt.wantURL, tt.wantRef, tt.wantSubdir)
+		}
+	}
+}
+
+func TestIsGitSource(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"git://github.com/org/repo", true},
+		{"git@github.com:org/repo.git", true},
+		{"https://github.com/org/repo.git", true},
+		{"https://example.com/app.tar.gz", false},
+		{"https://example.com/app.zip", false},
+	}
+
+	for _, tt := range tests {
+		if got := isGitSource(tt.url); got != tt.want {
+			t.Errorf("isGitSource(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package builders
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	repoComponentPattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+	hostComponentPattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?::[0-9]+)?$`)
+	tagComponentPattern  = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+)
+
+// validateImageTag enforces the standard docker `repo[:tag]` character set
+// so that a misspelled tag fails fast, before the multi-minute build runs,
+// rather than once it's handed to the container runtime.
+func validateImageTag(tag string) error {
+	repo, tagComponent, hasTagComponent := splitImageTag(tag)
+
+	if repo == "" {
+		return fmt.Errorf("image tag %q has an empty repository", tag)
+	}
+
+	for i, component := range strings.Split(repo, "/") {
+		// The first path component may be a registry host, optionally with
+		// a port (e.g. "localhost:5000", "registry.example.com:5000").
+		pattern := repoComponentPattern
+		if i == 0 {
+			pattern = hostComponentPattern
+		}
+
+		if !pattern.MatchString(component) {
+			return fmt.Errorf("image tag %q has an invalid repository component %q", tag, component)
+		}
+	}
+
+	if hasTagComponent {
+		if tagComponent == "" {
+			return fmt.Errorf("image tag %q has an empty tag after ':'", tag)
+		}
+
+		if !tagComponentPattern.MatchString(tagComponent) {
+			return fmt.Errorf("image tag %q has an invalid tag component %q", tag, tagComponent)
+		}
+	}
+
+	return nil
+}
+
+// splitImageTag splits a `repo:tag` reference into its repository and tag
+// components. Only a colon in the last path segment is treated as a tag
+// separator; a colon before the last "/" (e.g. a registry port,
+// `registry.example.com:5000/team/myfn`) is not.
+func splitImageTag(tag string) (repo string, tagComponent string, hasTagComponent bool) {
+	lastSlash := strings.LastIndex(tag, "/")
+	lastColon := strings.LastIndex(tag, ":")
+
+	if lastColon == -1 || lastColon < lastSlash {
+		return tag, "", false
+	}
+
+	return tag[:lastColon], tag[lastColon+1:], true
+}
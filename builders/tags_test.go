@@ -0,0 +1,68 @@
+package builders
+
+import "testing"
+
+func TestValidateImageTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		wantErr bool
+	}{
+		{"myfn:latest", false},
+		{"myfn:git-sha", false},
+		{"myfn", false},
+		{"registry.example.com/team/myfn:prod", false},
+		{"localhost:5000/myimage:latest", false},
+		{"registry.example.com:5000/team/myfn:latest", false},
+		{"lambda-builder/myfn:latest", false},
+		{"", true},
+		{":latest", true},
+		{"myfn:", true},
+		{"MyFn:latest", true},
+		{"myfn:Latest!", true},
+	}
+
+	for _, tt := range tests {
+		err := validateImageTag(tt.tag)
+		if tt.wantErr && err == nil {
+			t.Errorf("validateImageTag(%q) = nil, want error", tt.tag)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("validateImageTag(%q) = %v, want nil", tt.tag, err)
+		}
+	}
+}
+
+func TestSplitImageTag(t *testing.T) {
+	tests := []struct {
+		tag        string
+		wantRepo   string
+		wantTag    string
+		wantHasTag bool
+	}{
+		{"myfn", "myfn", "", false},
+		{"myfn:latest", "myfn", "latest", true},
+		{"localhost:5000/myimage", "localhost:5000/myimage", "", false},
+		{"localhost:5000/myimage:latest", "localhost:5000/myimage", "latest", true},
+	}
+
+	for _, tt := range tests {
+		repo, tag, hasTag := splitImageTag(tt.tag)
+		if repo != tt.wantRepo || tag != tt.wantTag || hasTag != tt.wantHasTag {
+			t.Errorf("splitImageTag(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.tag, repo, tag, hasTag, tt.wantRepo, tt.wantTag, tt.wantHasTag)
+		}
+	}
+}
+
+func TestGetImageTagsDefaultIsLowercased(t *testing.T) {
+	config := Config{WorkingDirectory: "/projects/MyLambdaFn"}
+
+	tags := config.GetImageTags()
+	if len(tags) != 1 {
+		t.Fatalf("GetImageTags() = %v, want exactly one default tag", tags)
+	}
+
+	if err := validateImageTag(tags[0]); err != nil {
+		t.Errorf("default tag %q failed validation: %v", tags[0], err)
+	}
+}
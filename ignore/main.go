@@ -0,0 +1,201 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a single compiled line from a .lambdaignore/.dockerignore file.
+type Pattern struct {
+	Negate  bool
+	DirOnly bool
+	regexp  *regexp.Regexp
+}
+
+// ReadPatterns loads the ignore patterns for projectDir, preferring
+// .lambdaignore and falling back to .dockerignore. If neither file exists,
+// it returns a nil, non-error result.
+func ReadPatterns(projectDir string) ([]Pattern, error) {
+	for _, name := range []string{".lambdaignore", ".dockerignore"} {
+		path := filepath.Join(projectDir, name)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error opening %s: %w", name, err)
+		}
+		defer f.Close()
+
+		return parsePatterns(f)
+	}
+
+	return nil, nil
+}
+
+func parsePatterns(f *os.File) ([]Pattern, error) {
+	var patterns []Pattern
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := compilePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing ignore pattern %q: %w", line, err)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ignore file: %w", err)
+	}
+
+	return patterns, nil
+}
+
+func compilePattern(line string) (Pattern, error) {
+	negate := strings.HasPrefix(line, "!")
+	if negate {
+		line = line[1:]
+	}
+
+	dirOnly := strings.HasSuffix(line, "/")
+	line = strings.TrimSuffix(line, "/")
+
+	rooted := strings.HasPrefix(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr, err := globToRegexp(line, rooted)
+	if err != nil {
+		return Pattern{}, err
+	}
+
+	return Pattern{Negate: negate, DirOnly: dirOnly, regexp: expr}, nil
+}
+
+// globToRegexp translates a gitignore-style glob (supporting **, *, ? and
+// directory-anchored patterns) into an anchored regular expression matching
+// a forward-slash-separated relative path. rooted indicates the pattern had
+// a leading "/" (already stripped from glob by the caller) and so must only
+// match at the project root, even though a leading "/" leaves no "/" left
+// in glob for the middle-slash heuristic below to notice.
+func globToRegexp(glob string, rooted bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	segments := strings.Split(glob, "/")
+	prevWasDoubleStar := false
+	for i, segment := range segments {
+		if i > 0 && !prevWasDoubleStar {
+			b.WriteString("/")
+		}
+
+		if segment == "**" {
+			if i == len(segments)-1 {
+				b.WriteString(".*")
+			} else {
+				// A "**" directory segment matches zero or more path
+				// segments, so the separator before what follows it is
+				// optional too: "**/foo" must also match root-level "foo".
+				b.WriteString("(?:.*/)?")
+			}
+			prevWasDoubleStar = true
+			continue
+		}
+		prevWasDoubleStar = false
+
+		for _, r := range segment {
+			switch r {
+			case '*':
+				b.WriteString("[^/]*")
+			case '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+	}
+
+	// An unanchored pattern (no leading "/" and no "/" in the middle) may
+	// match at any depth, mirroring gitignore semantics.
+	if !rooted && !strings.Contains(glob, "/") {
+		b.Reset()
+		b.WriteString("^(.*/)?")
+		for _, r := range glob {
+			switch r {
+			case '*':
+				b.WriteString("[^/]*")
+			case '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+	}
+
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+// Matches reports whether relPath (using forward slashes, relative to the
+// build context root) is ignored by patterns. Later patterns take
+// precedence over earlier ones, and a "!"-negated pattern re-includes a
+// path excluded by an earlier pattern, matching Docker's dockerignore
+// semantics.
+func Matches(patterns []Pattern, relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+
+	ignored := false
+	for _, pattern := range patterns {
+		if pattern.DirOnly && !isDir {
+			continue
+		}
+
+		if pattern.regexp.MatchString(relPath) {
+			ignored = !pattern.Negate
+		}
+	}
+
+	return ignored
+}
+
+// Prune removes every file and directory under root that matches patterns.
+func Prune(root string, patterns []Pattern) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if !Matches(patterns, relPath, info.IsDir()) {
+			return nil
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("error removing ignored path %s: %w", relPath, err)
+		}
+
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
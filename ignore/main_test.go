@@ -0,0 +1,61 @@
+package ignore
+
+import "testing"
+
+func mustCompile(t *testing.T, line string) Pattern {
+	t.Helper()
+
+	pattern, err := compilePattern(line)
+	if err != nil {
+		t.Fatalf("compilePattern(%q) returned error: %v", line, err)
+	}
+
+	return pattern
+}
+
+func TestCompilePatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"**/*.log", "app.log", false, true},
+		{"**/*.log", "sub/app.log", false, true},
+		{"**/*.log", "app.txt", false, false},
+		{"node_modules", "node_modules", true, true},
+		{"node_modules", "src/node_modules", true, true},
+		{"node_modules/**", "node_modules/.cache/file", false, true},
+		{"*.log", "app.log", false, true},
+		{"*.log", "sub/app.log", false, true},
+		{".git/", ".git", true, true},
+		{".git/", ".git", false, false},
+		{"/build", "build", false, true},
+		{"/build", "sub/build", false, false},
+	}
+
+	for _, tt := range tests {
+		pattern := mustCompile(t, tt.pattern)
+		got := Matches([]Pattern{pattern}, tt.path, tt.isDir)
+		if got != tt.want {
+			t.Errorf("pattern %q matching %q (isDir=%v) = %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesNegation(t *testing.T) {
+	patterns := []Pattern{
+		mustCompile(t, "*.log"),
+		mustCompile(t, "!important.log"),
+	}
+
+	if !Matches(patterns, "debug.log", false) {
+		t.Errorf("expected debug.log to be ignored")
+	}
+
+	if Matches(patterns, "important.log", false) {
+		t.Errorf("expected important.log to be re-included by negation")
+	}
+}
@@ -0,0 +1,53 @@
+// Package progress defines a small, dependency-free subset of Docker's
+// jsonmessage wire format, used to report structured build progress to
+// callers embedding lambda-builder as a library.
+package progress
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ProgressDetail carries a current/total pair for a step that's in flight,
+// e.g. "docker build step 3/7".
+type ProgressDetail struct {
+	Current int64 `json:"current,omitempty"`
+	Total   int64 `json:"total,omitempty"`
+}
+
+// ErrorDetail carries a human-readable error message for a failed message.
+type ErrorDetail struct {
+	Message string `json:"message,omitempty"`
+}
+
+// Aux carries side-channel data, such as the final built image ID.
+type Aux struct {
+	ID string `json:"ID,omitempty"`
+}
+
+// JSONMessage is a single structured progress record, modeled after
+// Docker's `jsonmessage.JSONMessage`.
+type JSONMessage struct {
+	Stream         string          `json:"stream,omitempty"`
+	Status         string          `json:"status,omitempty"`
+	ProgressDetail *ProgressDetail `json:"progressDetail,omitempty"`
+	ErrorDetail    *ErrorDetail    `json:"errorDetail,omitempty"`
+	Aux            *Aux            `json:"aux,omitempty"`
+}
+
+// Emit writes msg to w as a single line of newline-delimited JSON. It is a
+// no-op when w is nil, so call sites don't need to guard every call.
+func Emit(w io.Writer, msg JSONMessage) error {
+	if w == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}